@@ -5,11 +5,16 @@ provided operation either returns nil (indicating success) or it hits an attempt
 package backoff
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"time"
 )
 
 var ErrMaxAttemptsReached = fmt.Errorf("max attempts reached")
+var ErrMaxElapsedTimeReached = fmt.Errorf("max elapsed time reached")
+var ErrPerAttemptTimeout = fmt.Errorf("per-attempt timeout exceeded")
 
 // OperationFunc should return nil on success, otherwise returns an error
 type OperationFunc = func() error
@@ -20,8 +25,92 @@ type RetryFunc = func(error, time.Duration)
 // SleepFunc can be used to replace the default time.Sleep function, for example in unit tests
 type SleepFunc = func(time.Duration)
 
+// RetryIfFunc decides whether an operation error should be retried. Returning false stops
+// Retry immediately, without sleeping or consuming any further attempts.
+type RetryIfFunc = func(error) bool
+
 type Option func(*backoff)
 
+// JitterKind selects how the delay between attempts is randomized before sleeping.
+type JitterKind int
+
+const (
+	// JitterNone sleeps for exactly the computed backoff duration, this is the default.
+	JitterNone JitterKind = iota
+	// JitterFull sleeps for a random duration between 0 and the computed backoff duration.
+	JitterFull
+	// JitterEqual sleeps for half the computed backoff duration, plus a random duration up to the other half.
+	JitterEqual
+	// JitterDecorrelated sleeps for a random duration derived from the previous sleep, AWS-style.
+	JitterDecorrelated
+)
+
+// Strategy computes the sleep duration to use after each failed attempt. Next is called
+// with the 1-based attempt number and the previously computed duration (the Min duration
+// on the first call), and should return the next duration before Min/Max bounding and jitter are
+// applied. Reset is called at the start of every Retry/RetryWithData call, so a stateful
+// Strategy instance can be reused across independent retry loops.
+type Strategy interface {
+	Next(attempt int, last time.Duration) time.Duration
+	Reset()
+}
+
+// ConstantStrategy sleeps for the same Interval after every attempt.
+type ConstantStrategy struct {
+	Interval time.Duration
+}
+
+func (s *ConstantStrategy) Next(_ int, _ time.Duration) time.Duration {
+	return s.Interval
+}
+
+func (s *ConstantStrategy) Reset() {}
+
+// LinearStrategy sleeps for Step multiplied by the attempt number, growing by a fixed
+// amount every attempt instead of doubling.
+type LinearStrategy struct {
+	Step time.Duration
+}
+
+func (s *LinearStrategy) Next(attempt int, _ time.Duration) time.Duration {
+	return s.Step * time.Duration(attempt)
+}
+
+func (s *LinearStrategy) Reset() {}
+
+// ExponentialStrategy multiplies the previous duration by Multiplier after every attempt.
+// This is the strategy Retry uses by default, driven by WithMultiplier.
+type ExponentialStrategy struct {
+	Multiplier float64
+}
+
+func (s *ExponentialStrategy) Next(_ int, last time.Duration) time.Duration {
+	return time.Duration(float64(last) * s.Multiplier)
+}
+
+func (s *ExponentialStrategy) Reset() {}
+
+// FibonacciStrategy grows the sleep duration along the Fibonacci sequence scaled by Unit,
+// i.e. Unit, 2*Unit, 3*Unit, 5*Unit, 8*Unit, and so on.
+type FibonacciStrategy struct {
+	Unit time.Duration
+
+	prev time.Duration
+	curr time.Duration
+}
+
+func (s *FibonacciStrategy) Next(_ int, _ time.Duration) time.Duration {
+	if s.curr == 0 {
+		s.prev, s.curr = 0, s.Unit
+	}
+	s.prev, s.curr = s.curr, s.prev+s.curr
+	return s.curr
+}
+
+func (s *FibonacciStrategy) Reset() {
+	s.prev, s.curr = 0, 0
+}
+
 // WithRetryFunc option is used to set a function to be executed before sleeping in a retry, the arguments are
 // the operation function error returned, and the upcoming sleep duration
 func WithRetryFunc(retry RetryFunc) Option {
@@ -42,6 +131,7 @@ func WithMinDuration(d time.Duration) Option {
 	return func(b *backoff) {
 		b.Min = d
 		b.current = d
+		b.prevSleep = d
 	}
 }
 
@@ -60,71 +150,437 @@ func WithMaxAttempts(attempts int) Option {
 	}
 }
 
+// WithContext makes Retry cancellable. Once ctx is done, Retry stops waiting for the
+// next attempt and returns ctx.Err() joined with the last operation error.
+func WithContext(ctx context.Context) Option {
+	return func(b *backoff) {
+		b.ctx = ctx
+	}
+}
+
+// WithJitter randomizes the sleep duration on each attempt according to kind, instead of
+// sleeping for exactly the computed backoff duration. This helps avoid retry storms when
+// many clients back off in lockstep.
+func WithJitter(kind JitterKind) Option {
+	return func(b *backoff) {
+		b.jitter = kind
+	}
+}
+
+// WithMultiplier sets the growth factor used by the default ExponentialStrategy. Defaults
+// to 2. Has no effect once a custom Strategy is installed via WithStrategy.
+func WithMultiplier(multiplier float64) Option {
+	return func(b *backoff) {
+		b.multiplier = multiplier
+	}
+}
+
+// WithStrategy installs a custom Strategy for computing the sleep duration after each
+// failed attempt, replacing the default ExponentialStrategy. WithMinDuration and
+// WithMaxDuration still bound whatever the strategy returns.
+func WithStrategy(strategy Strategy) Option {
+	return func(b *backoff) {
+		b.strategy = strategy
+	}
+}
+
+// WithRand replaces the source of randomness used by WithJitter, this is internally used
+// for unit tests to get deterministic jitter.
+func WithRand(rnd *rand.Rand) Option {
+	return func(b *backoff) {
+		b.rand = rnd
+	}
+}
+
+// WithRetryIf installs a predicate that decides whether a failed operation should be
+// retried. By default, every non-nil error is retried. Returning false from retryIf stops
+// Retry immediately and returns the original error wrapped as a *NotRetryableError.
+func WithRetryIf(retryIf RetryIfFunc) Option {
+	return func(b *backoff) {
+		b.retryIf = retryIf
+	}
+}
+
+// WithMaxElapsedTime gives up once the cumulative wall-clock time since the first attempt
+// exceeds d, independent of how many attempts have been made, returning a *RetryError
+// that matches ErrMaxElapsedTimeReached via errors.Is.
+func WithMaxElapsedTime(d time.Duration) Option {
+	return func(b *backoff) {
+		b.maxElapsedTime = d
+	}
+}
+
+// WithPerAttemptTimeout aborts a single attempt if operation has not returned within d. If
+// WithContext is also set, the attempt runs under a context.WithTimeout derived from it;
+// otherwise a watchdog timer aborts the attempt on its own. In both cases, operation keeps
+// running in the background and its result is discarded once the attempt is abandoned, as
+// OperationFunc has no way to observe the timeout itself.
+func WithPerAttemptTimeout(d time.Duration) Option {
+	return func(b *backoff) {
+		b.perAttemptTimeout = d
+	}
+}
+
 type backoff struct {
 	Min      time.Duration
 	Max      time.Duration
 	Attempts int
 
-	retryFunc RetryFunc
-	sleepFunc SleepFunc
-	current   time.Duration
-	attempt   int
+	ctx               context.Context
+	retryFunc         RetryFunc
+	sleepFunc         SleepFunc
+	retryIf           RetryIfFunc
+	jitter            JitterKind
+	multiplier        float64
+	rand              *rand.Rand
+	maxElapsedTime    time.Duration
+	perAttemptTimeout time.Duration
+	strategy          Strategy
+	startTime         time.Time
+	current           time.Duration
+	prevSleep         time.Duration
+	attempt           int
+	errs              []error
+}
+
+// NotRetryableError is returned by Retry when an operation error was classified as
+// terminal, either by WithRetryIf returning false or by the operation wrapping its error
+// with Unrecoverable.
+type NotRetryableError struct {
+	Err error
+}
+
+func (e *NotRetryableError) Error() string {
+	return fmt.Sprintf("not retryable: %s", e.Err.Error())
+}
+
+func (e *NotRetryableError) Unwrap() error {
+	return e.Err
+}
+
+// RetryError is returned by Retry once it gives up, either because WithMaxAttempts or
+// WithMaxElapsedTime was reached. It aggregates every attempt's error so callers can see
+// why each one failed, not just the last.
+//
+// errors.Is(retryErr, ErrMaxAttemptsReached) and errors.Is(retryErr, ErrMaxElapsedTimeReached)
+// still work, matching whichever condition actually triggered the give-up.
+type RetryError struct {
+	final error
+	errs  []error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%s: %s", e.final.Error(), e.LastError())
+}
+
+func (e *RetryError) Unwrap() []error {
+	return append([]error{e.final}, e.errs...)
+}
+
+// Attempts returns how many attempts were made before giving up.
+func (e *RetryError) Attempts() int {
+	return len(e.errs)
+}
+
+// LastError returns the error from the final attempt.
+func (e *RetryError) LastError() error {
+	if len(e.errs) == 0 {
+		return nil
+	}
+	return e.errs[len(e.errs)-1]
+}
+
+// Errors returns the error from every attempt, in the order they occurred.
+func (e *RetryError) Errors() []error {
+	return e.errs
+}
+
+type unrecoverableError struct {
+	err error
+}
+
+func (e *unrecoverableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *unrecoverableError) Unwrap() error {
+	return e.err
+}
+
+// Unrecoverable wraps err so that Retry stops immediately instead of continuing to retry,
+// regardless of any WithRetryIf predicate.
+func Unrecoverable(err error) error {
+	return &unrecoverableError{err: err}
+}
+
+// shouldRetry reports whether err should be retried, consulting the installed
+// WithRetryIf predicate and any Unrecoverable wrapping.
+func (b *backoff) shouldRetry(err error) bool {
+	var unrecoverable *unrecoverableError
+	if errors.As(err, &unrecoverable) {
+		return false
+	}
+
+	if b.retryIf != nil {
+		return b.retryIf(err)
+	}
+
+	return true
 }
 
 func (b *backoff) retry(err error) error {
 	b.attempt += 1
+	b.errs = append(b.errs, err)
+
 	if b.Attempts > 0 && b.attempt >= b.Attempts {
-		return ErrMaxAttemptsReached
+		return &RetryError{final: ErrMaxAttemptsReached, errs: b.errs}
+	}
+	if b.maxElapsedTime > 0 && time.Since(b.startTime) >= b.maxElapsedTime {
+		return &RetryError{final: ErrMaxElapsedTimeReached, errs: b.errs}
 	}
 
+	d := b.jitteredDuration()
+
 	if b.retryFunc != nil {
-		b.retryFunc(err, b.current)
+		b.retryFunc(err, d)
 	}
-	b.sleepFunc(b.current)
-	if b.current < b.Max {
-		b.current *= 2
-		if b.current > b.Max {
-			b.current = b.Max
-		}
+
+	if sleepErr := b.sleep(d); sleepErr != nil {
+		return errors.Join(sleepErr, err)
+	}
+	b.prevSleep = d
+
+	b.current = b.strategy.Next(b.attempt, b.current)
+	if b.current > b.Max {
+		b.current = b.Max
+	}
+	if b.current < b.Min {
+		b.current = b.Min
 	}
 
 	return nil
 }
 
+// jitteredDuration applies the configured JitterKind to the current backoff duration.
+func (b *backoff) jitteredDuration() time.Duration {
+	switch b.jitter {
+	case JitterFull:
+		return time.Duration(b.int63n(int64(b.current)))
+	case JitterEqual:
+		half := b.current / 2
+		return half + time.Duration(b.int63n(int64(half)))
+	case JitterDecorrelated:
+		upper := 3*b.prevSleep - b.Min
+		if upper <= 0 {
+			upper = b.Min
+		}
+		d := b.Min + time.Duration(b.int63n(int64(upper)))
+		if d > b.Max {
+			d = b.Max
+		}
+		return d
+	default:
+		return b.current
+	}
+}
+
+// int63n returns a random number in [0, n) using the configured rand source, or the
+// global math/rand source if none was installed via WithRand. n <= 0 returns 0.
+func (b *backoff) int63n(n int64) int64 {
+	if n <= 0 {
+		return 0
+	}
+	if b.rand != nil {
+		return b.rand.Int63n(n)
+	}
+	return rand.Int63n(n)
+}
+
+// sleep waits for d to pass, the same way sleepFunc always has. If a context was
+// installed via WithContext, it instead races the wait against ctx.Done() so a
+// cancelled context interrupts the wait immediately.
+func (b *backoff) sleep(d time.Duration) error {
+	if b.ctx == nil {
+		b.sleepFunc(d)
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-b.ctx.Done():
+		return b.ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
 func newBackoff(options []Option) backoff {
 	backoff := backoff{
-		Min:       time.Second,
-		Max:       time.Minute,
-		sleepFunc: time.Sleep,
-		current:   time.Second,
-		attempt:   0,
+		Min:        time.Second,
+		Max:        time.Minute,
+		sleepFunc:  time.Sleep,
+		jitter:     JitterNone,
+		multiplier: 2,
+		current:    time.Second,
+		prevSleep:  time.Second,
+		startTime:  time.Now(),
+		attempt:    0,
 	}
 
 	for _, option := range options {
 		option(&backoff)
 	}
 
+	if backoff.strategy == nil {
+		backoff.strategy = &ExponentialStrategy{Multiplier: backoff.multiplier}
+	}
+	backoff.strategy.Reset()
+
 	return backoff
 }
 
+// runOperation executes operation, aborting the attempt once perAttemptTimeout elapses.
+// operation keeps running in the background past the timeout; it has no way to cancel it
+// itself since OperationFunc takes no context.
+func (b *backoff) runOperation(operation OperationFunc) error {
+	if b.perAttemptTimeout <= 0 {
+		return operation()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- operation()
+	}()
+
+	if b.ctx != nil {
+		ctx, cancel := context.WithTimeout(b.ctx, b.perAttemptTimeout)
+		defer cancel()
+
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	timer := time.NewTimer(b.perAttemptTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return ErrPerAttemptTimeout
+	}
+}
+
 // Retry attempts to run operation until it no longer returns an error.
 // It will exponentially increase the time between each attempt until it reaches max.
 //
 // By default, it will start with a 1-second delay, which will double every attempt until it caps off at 1 minute.
 // It will retry infinitely unless the WithMaxAttempts option is set
 //
-// returns nil or ErrMaxAttemptsReached
+// Use WithJitter to randomize the sleep duration, WithMultiplier to change the growth
+// factor, or WithStrategy to replace exponential growth entirely with a constant, linear,
+// Fibonacci, or custom schedule.
+//
+// If WithContext is set, Retry stops waiting and returns once the context is done.
+//
+// Use WithRetryIf, or wrap an operation error with Unrecoverable, to stop retrying
+// immediately on terminal errors; Retry then returns a *NotRetryableError.
+//
+// Use WithMaxElapsedTime to bound the total wall-clock time spent retrying, and
+// WithPerAttemptTimeout to bound how long a single attempt may run.
+//
+// returns nil, a *RetryError, a *NotRetryableError, or the context error joined with the
+// last operation error
 func Retry(operation OperationFunc, options ...Option) error {
 	backoff := newBackoff(options)
 	for {
-		err := operation()
+		err := backoff.runOperation(operation)
 		if err == nil {
 			return nil
 		}
 
+		if !backoff.shouldRetry(err) {
+			return &NotRetryableError{Err: err}
+		}
+
 		err = backoff.retry(err)
 		if err != nil {
 			return err
 		}
 	}
 }
+
+// RetryWithData attempts to run operation until it no longer returns an error, returning
+// the value produced by the final successful call. It shares the same backoff, retry, and
+// option machinery as Retry.
+//
+// returns the zero value of T alongside whatever error Retry would otherwise return
+func RetryWithData[T any](operation func() (T, error), options ...Option) (T, error) {
+	backoff := newBackoff(options)
+	for {
+		result, err := runOperationWithData(&backoff, operation)
+		if err == nil {
+			return result, nil
+		}
+
+		if !backoff.shouldRetry(err) {
+			var zero T
+			return zero, &NotRetryableError{Err: err}
+		}
+
+		err = backoff.retry(err)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+}
+
+// runOperationWithData is the generic counterpart of (*backoff).runOperation, used by
+// RetryWithData.
+func runOperationWithData[T any](b *backoff, operation func() (T, error)) (T, error) {
+	if b.perAttemptTimeout <= 0 {
+		return operation()
+	}
+
+	type attemptResult struct {
+		value T
+		err   error
+	}
+
+	done := make(chan attemptResult, 1)
+	go func() {
+		value, err := operation()
+		done <- attemptResult{value: value, err: err}
+	}()
+
+	if b.ctx != nil {
+		ctx, cancel := context.WithTimeout(b.ctx, b.perAttemptTimeout)
+		defer cancel()
+
+		select {
+		case r := <-done:
+			return r.value, r.err
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+
+	timer := time.NewTimer(b.perAttemptTimeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-timer.C:
+		var zero T
+		return zero, ErrPerAttemptTimeout
+	}
+}
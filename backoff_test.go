@@ -1,9 +1,11 @@
 package backoff
 
 import (
+	"context"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"math/rand"
 	"testing"
 	"time"
 )
@@ -65,7 +67,7 @@ func TestRetry(t *testing.T) {
 
 		err := Retry(helper.Operation(), WithSleepFunc(helper.Sleep()), WithMaxAttempts(10))
 
-		assert.EqualError(t, err, "max attempts reached")
+		assert.EqualError(t, err, "max attempts reached: failed successfully")
 		assert.Lenf(t, helper.operationCalls, 10, "operation calls")
 		assert.Lenf(t, helper.sleepCalls, 9, "sleep calls")
 	})
@@ -76,7 +78,7 @@ func TestRetry(t *testing.T) {
 
 		err := Retry(helper.Operation(), WithSleepFunc(helper.Sleep()), WithMaxAttempts(3), WithRetryFunc(helper.Retry()))
 
-		assert.EqualError(t, err, "max attempts reached")
+		assert.EqualError(t, err, "max attempts reached: failed successfully")
 		assert.Lenf(t, helper.operationCalls, 3, "operation calls")
 		assert.Lenf(t, helper.sleepCalls, 2, "sleep calls")
 
@@ -92,7 +94,7 @@ func TestRetry(t *testing.T) {
 		helper.operationRetVal = fmt.Errorf("failed successfully")
 
 		err := Retry(helper.Operation(), WithSleepFunc(helper.Sleep()), WithMaxDuration(10*time.Second), WithMaxAttempts(7))
-		assert.EqualError(t, err, "max attempts reached")
+		assert.EqualError(t, err, "max attempts reached: failed successfully")
 		require.Lenf(t, helper.sleepCalls, 6, "sleep calls")
 		assert.Equalf(t, 1*time.Second, helper.sleepCalls[0], "first sleep")
 		assert.Equalf(t, 2*time.Second, helper.sleepCalls[1], "second sleep")
@@ -107,9 +109,272 @@ func TestRetry(t *testing.T) {
 		helper.operationRetVal = fmt.Errorf("failed successfully")
 
 		err := Retry(helper.Operation(), WithSleepFunc(helper.Sleep()), WithMinDuration(10*time.Second), WithMaxAttempts(3))
-		assert.EqualError(t, err, "max attempts reached")
+		assert.EqualError(t, err, "max attempts reached: failed successfully")
 		require.Lenf(t, helper.sleepCalls, 2, "sleep calls")
 		assert.Equalf(t, 10*time.Second, helper.sleepCalls[0], "first sleep")
 		assert.Equalf(t, 20*time.Second, helper.sleepCalls[1], "second sleep")
 	})
+
+	t.Run("context cancellation", func(t *testing.T) {
+		helper := newRetryHelper()
+		helper.operationRetVal = fmt.Errorf("failed successfully")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := Retry(helper.Operation(), WithContext(ctx), WithSleepFunc(helper.Sleep()))
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorContains(t, err, "failed successfully")
+		assert.Empty(t, helper.sleepCalls, "sleepFunc should not be used once a context is installed")
+	})
+
+	t.Run("full jitter", func(t *testing.T) {
+		helper := newRetryHelper()
+		helper.operationRetVal = fmt.Errorf("failed successfully")
+
+		err := Retry(
+			helper.Operation(),
+			WithSleepFunc(helper.Sleep()),
+			WithMaxAttempts(3),
+			WithJitter(JitterFull),
+			WithRand(rand.New(rand.NewSource(1))),
+		)
+
+		assert.EqualError(t, err, "max attempts reached: failed successfully")
+		require.Lenf(t, helper.sleepCalls, 2, "sleep calls")
+		assert.Lessf(t, helper.sleepCalls[0], time.Second, "first sleep should be within [0, 1s)")
+		assert.Lessf(t, helper.sleepCalls[1], 2*time.Second, "second sleep should be within [0, 2s)")
+	})
+
+	t.Run("equal jitter", func(t *testing.T) {
+		helper := newRetryHelper()
+		helper.operationRetVal = fmt.Errorf("failed successfully")
+
+		err := Retry(
+			helper.Operation(),
+			WithSleepFunc(helper.Sleep()),
+			WithMaxAttempts(3),
+			WithJitter(JitterEqual),
+			WithRand(rand.New(rand.NewSource(1))),
+		)
+
+		assert.EqualError(t, err, "max attempts reached: failed successfully")
+		require.Lenf(t, helper.sleepCalls, 2, "sleep calls")
+		assert.GreaterOrEqualf(t, helper.sleepCalls[0], 500*time.Millisecond, "first sleep should be at least half of 1s")
+		assert.Lessf(t, helper.sleepCalls[0], time.Second, "first sleep should be less than 1s")
+	})
+
+	t.Run("decorrelated jitter", func(t *testing.T) {
+		helper := newRetryHelper()
+		helper.operationRetVal = fmt.Errorf("failed successfully")
+
+		err := Retry(
+			helper.Operation(),
+			WithSleepFunc(helper.Sleep()),
+			WithMaxDuration(10*time.Second),
+			WithMaxAttempts(5),
+			WithJitter(JitterDecorrelated),
+			WithRand(rand.New(rand.NewSource(1))),
+		)
+
+		assert.EqualError(t, err, "max attempts reached: failed successfully")
+		require.Lenf(t, helper.sleepCalls, 4, "sleep calls")
+		for _, d := range helper.sleepCalls {
+			assert.GreaterOrEqualf(t, d, time.Second, "sleep should never go below Min")
+			assert.LessOrEqualf(t, d, 10*time.Second, "sleep should never exceed Max")
+		}
+	})
+
+	t.Run("custom multiplier", func(t *testing.T) {
+		helper := newRetryHelper()
+		helper.operationRetVal = fmt.Errorf("failed successfully")
+
+		err := Retry(helper.Operation(), WithSleepFunc(helper.Sleep()), WithMaxAttempts(3), WithMultiplier(1.5))
+
+		assert.EqualError(t, err, "max attempts reached: failed successfully")
+		require.Lenf(t, helper.sleepCalls, 2, "sleep calls")
+		assert.Equalf(t, time.Second, helper.sleepCalls[0], "first sleep")
+		assert.Equalf(t, 1500*time.Millisecond, helper.sleepCalls[1], "second sleep")
+	})
+
+	t.Run("retry if predicate stops early", func(t *testing.T) {
+		helper := newRetryHelper()
+		helper.operationRetVal = fmt.Errorf("bad credentials")
+
+		err := Retry(
+			helper.Operation(),
+			WithSleepFunc(helper.Sleep()),
+			WithMaxAttempts(10),
+			WithRetryIf(func(err error) bool {
+				return err.Error() != "bad credentials"
+			}),
+		)
+
+		var notRetryable *NotRetryableError
+		require.ErrorAs(t, err, &notRetryable)
+		assert.EqualError(t, notRetryable.Err, "bad credentials")
+		assert.Lenf(t, helper.operationCalls, 1, "operation calls")
+		assert.Empty(t, helper.sleepCalls, "sleep calls")
+	})
+
+	t.Run("unrecoverable error stops early", func(t *testing.T) {
+		helper := newRetryHelper()
+		helper.operationRetVal = Unrecoverable(fmt.Errorf("bad credentials"))
+
+		err := Retry(helper.Operation(), WithSleepFunc(helper.Sleep()), WithMaxAttempts(10))
+
+		var notRetryable *NotRetryableError
+		require.ErrorAs(t, err, &notRetryable)
+		assert.EqualError(t, notRetryable.Err, "bad credentials")
+		assert.Lenf(t, helper.operationCalls, 1, "operation calls")
+		assert.Empty(t, helper.sleepCalls, "sleep calls")
+	})
+
+	t.Run("max elapsed time", func(t *testing.T) {
+		helper := newRetryHelper()
+		helper.operationRetVal = fmt.Errorf("failed successfully")
+
+		err := Retry(
+			helper.Operation(),
+			WithSleepFunc(func(time.Duration) { time.Sleep(5 * time.Millisecond) }),
+			WithMinDuration(time.Millisecond),
+			WithMaxElapsedTime(10*time.Millisecond),
+		)
+
+		assert.ErrorIs(t, err, ErrMaxElapsedTimeReached)
+	})
+
+	t.Run("per attempt timeout", func(t *testing.T) {
+		err := Retry(
+			func() error {
+				time.Sleep(50 * time.Millisecond)
+				return nil
+			},
+			WithSleepFunc(func(time.Duration) {}),
+			WithPerAttemptTimeout(5*time.Millisecond),
+			WithMaxAttempts(2),
+		)
+
+		assert.EqualError(t, err, "max attempts reached: per-attempt timeout exceeded")
+	})
+
+	t.Run("per attempt timeout with context", func(t *testing.T) {
+		err := Retry(
+			func() error {
+				time.Sleep(50 * time.Millisecond)
+				return nil
+			},
+			WithContext(context.Background()),
+			WithSleepFunc(func(time.Duration) {}),
+			WithPerAttemptTimeout(5*time.Millisecond),
+			WithMaxAttempts(1),
+		)
+
+		assert.EqualError(t, err, "max attempts reached: context deadline exceeded")
+	})
+
+	t.Run("retry error aggregates every attempt", func(t *testing.T) {
+		helper := newRetryHelper()
+		helper.operationRetVal = fmt.Errorf("failed successfully")
+
+		err := Retry(helper.Operation(), WithSleepFunc(helper.Sleep()), WithMaxAttempts(3))
+
+		var retryErr *RetryError
+		require.ErrorAs(t, err, &retryErr)
+		assert.ErrorIs(t, err, ErrMaxAttemptsReached)
+		assert.Equal(t, 3, retryErr.Attempts())
+		assert.EqualError(t, retryErr.LastError(), "failed successfully")
+		assert.Len(t, retryErr.Errors(), 3)
+	})
+
+	t.Run("constant strategy", func(t *testing.T) {
+		helper := newRetryHelper()
+		helper.operationRetVal = fmt.Errorf("failed successfully")
+
+		err := Retry(
+			helper.Operation(),
+			WithSleepFunc(helper.Sleep()),
+			WithMaxAttempts(4),
+			WithStrategy(&ConstantStrategy{Interval: 5 * time.Second}),
+		)
+
+		assert.EqualError(t, err, "max attempts reached: failed successfully")
+		require.Lenf(t, helper.sleepCalls, 3, "sleep calls")
+		assert.Equalf(t, time.Second, helper.sleepCalls[0], "first sleep uses the default Min before the strategy kicks in")
+		assert.Equalf(t, 5*time.Second, helper.sleepCalls[1], "second sleep")
+		assert.Equalf(t, 5*time.Second, helper.sleepCalls[2], "third sleep")
+	})
+
+	t.Run("linear strategy", func(t *testing.T) {
+		helper := newRetryHelper()
+		helper.operationRetVal = fmt.Errorf("failed successfully")
+
+		err := Retry(
+			helper.Operation(),
+			WithSleepFunc(helper.Sleep()),
+			WithMaxAttempts(6),
+			WithStrategy(&LinearStrategy{Step: time.Second}),
+		)
+
+		assert.EqualError(t, err, "max attempts reached: failed successfully")
+		require.Lenf(t, helper.sleepCalls, 5, "sleep calls")
+		assert.Equalf(t, []time.Duration{time.Second, time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second}, helper.sleepCalls, "sleeps")
+	})
+
+	t.Run("fibonacci strategy", func(t *testing.T) {
+		helper := newRetryHelper()
+		helper.operationRetVal = fmt.Errorf("failed successfully")
+
+		err := Retry(
+			helper.Operation(),
+			WithSleepFunc(helper.Sleep()),
+			WithMaxAttempts(6),
+			WithStrategy(&FibonacciStrategy{Unit: time.Second}),
+		)
+
+		assert.EqualError(t, err, "max attempts reached: failed successfully")
+		require.Lenf(t, helper.sleepCalls, 5, "sleep calls")
+		assert.Equalf(t, []time.Duration{time.Second, time.Second, 2 * time.Second, 3 * time.Second, 5 * time.Second}, helper.sleepCalls, "sleeps")
+	})
+}
+
+func TestRetryWithData(t *testing.T) {
+	t.Run("immediate success returns the value", func(t *testing.T) {
+		calls := 0
+
+		result, err := RetryWithData(func() (string, error) {
+			calls++
+			return "ok", nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", result)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("returns the value from the attempt that finally succeeds", func(t *testing.T) {
+		calls := 0
+
+		result, err := RetryWithData(func() (int, error) {
+			calls++
+			if calls < 3 {
+				return 0, fmt.Errorf("not yet")
+			}
+			return 42, nil
+		}, WithSleepFunc(func(time.Duration) {}))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 42, result)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("max attempts returns the zero value", func(t *testing.T) {
+		result, err := RetryWithData(func() (int, error) {
+			return 7, fmt.Errorf("failed successfully")
+		}, WithSleepFunc(func(time.Duration) {}), WithMaxAttempts(3))
+
+		assert.EqualError(t, err, "max attempts reached: failed successfully")
+		assert.Equal(t, 0, result)
+	})
 }